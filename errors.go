@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailureClass classifies why a download attempt failed, replacing the old
+// substring-keyword guesswork with a fixed taxonomy that a RetryPolicy can
+// key off of.
+type FailureClass int
+
+const (
+	ErrUnknown FailureClass = iota
+	ErrRateLimited
+	ErrGeoBlocked
+	ErrAgeRestricted
+	ErrCopyrightBlocked
+	ErrDNS
+	ErrProxy
+	ErrTransientHTTP
+	ErrPermanentHTTP
+	ErrNotFound
+)
+
+func (c FailureClass) String() string {
+	switch c {
+	case ErrRateLimited:
+		return "rate limited"
+	case ErrGeoBlocked:
+		return "geo blocked"
+	case ErrAgeRestricted:
+		return "age restricted"
+	case ErrCopyrightBlocked:
+		return "copyright blocked"
+	case ErrDNS:
+		return "DNS error"
+	case ErrProxy:
+		return "proxy error"
+	case ErrTransientHTTP:
+		return "transient HTTP error"
+	case ErrPermanentHTTP:
+		return "permanent HTTP error"
+	case ErrNotFound:
+		return "not found"
+	default:
+		return "unknown error"
+	}
+}
+
+// ClassifiedError wraps the raw yt-dlp failure with its FailureClass so
+// callers can branch on class without re-parsing output.
+type ClassifiedError struct {
+	Class   FailureClass
+	Message string
+}
+
+func (e *ClassifiedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Class, e.Message)
+}
+
+// RetryPolicy describes, per FailureClass, how the worker loop should react:
+// how many times to retry, whether to rotate proxy/IP or yt-dlp extractor
+// client on the next attempt, and the backoff curve between attempts.
+type RetryPolicy struct {
+	MaxAttempts    int
+	RotateProxy    bool
+	RotateClient   bool
+	Backoff        func(attempt int) time.Duration
+	GlobalCooldown bool // trip the shared circuit breaker across all workers
+}
+
+// defaultRetryPolicies is keyed by FailureClass and mirrors the behavior the
+// worker loop previously hard-coded as a fixed retryCount with linear sleep.
+var defaultRetryPolicies = map[FailureClass]RetryPolicy{
+	ErrRateLimited: {
+		MaxAttempts:    4,
+		RotateProxy:    true,
+		Backoff:        exponentialBackoff(2 * time.Second),
+		GlobalCooldown: true,
+	},
+	ErrProxy: {
+		MaxAttempts: 3,
+		RotateProxy: true,
+		Backoff:     exponentialBackoff(time.Second),
+	},
+	ErrDNS: {
+		MaxAttempts: 3,
+		RotateProxy: true,
+		Backoff:     exponentialBackoff(time.Second),
+	},
+	ErrTransientHTTP: {
+		MaxAttempts: 3,
+		RotateProxy: true,
+		Backoff:     linearBackoff(time.Second),
+	},
+	ErrNotFound: {
+		MaxAttempts:  1,
+		RotateClient: true,
+		Backoff:      linearBackoff(time.Second),
+	},
+	ErrUnknown: {
+		MaxAttempts: 2,
+		Backoff:     linearBackoff(time.Second),
+	},
+	// Non-retryable: we've learned the answer and retrying burns requests.
+	ErrGeoBlocked:       {MaxAttempts: 0},
+	ErrAgeRestricted:    {MaxAttempts: 0},
+	ErrCopyrightBlocked: {MaxAttempts: 0},
+	ErrPermanentHTTP:    {MaxAttempts: 0},
+}
+
+func exponentialBackoff(base time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		return base * time.Duration(1<<uint(attempt))
+	}
+}
+
+func linearBackoff(step time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		return step * time.Duration(attempt+1)
+	}
+}
+
+// policyFor looks up the RetryPolicy for class, falling back to the
+// ErrUnknown policy if the class has no explicit entry.
+func policyFor(class FailureClass) RetryPolicy {
+	if p, ok := defaultRetryPolicies[class]; ok {
+		return p
+	}
+	return defaultRetryPolicies[ErrUnknown]
+}
+
+// classifyFailure inspects yt-dlp's combined stdout/stderr and the process
+// error to assign a FailureClass, replacing the old keyword-substring
+// analyzeFailure.
+func classifyFailure(output string, cmdErr error) *ClassifiedError {
+	lower := strings.ToLower(output)
+	msg := strings.TrimSpace(output)
+	if msg == "" && cmdErr != nil {
+		msg = cmdErr.Error()
+	}
+
+	switch {
+	case containsAny(lower, "429", "too many requests", "rate-limit", "rate limit"):
+		return &ClassifiedError{Class: ErrRateLimited, Message: msg}
+	case containsAny(lower, "403", "forbidden"):
+		return &ClassifiedError{Class: ErrPermanentHTTP, Message: msg}
+	case containsAny(lower, "copyright", "blocked it in your country", "blocked it on copyright grounds"):
+		return &ClassifiedError{Class: ErrCopyrightBlocked, Message: msg}
+	case containsAny(lower, "not available in your country", "geo-restricted", "geo restricted"):
+		return &ClassifiedError{Class: ErrGeoBlocked, Message: msg}
+	case containsAny(lower, "age-restricted", "sign in to confirm your age"):
+		return &ClassifiedError{Class: ErrAgeRestricted, Message: msg}
+	case containsAny(lower, "no such host", "dns", "name resolution"):
+		return &ClassifiedError{Class: ErrDNS, Message: msg}
+	case containsAny(lower, "proxy", "socks", "tunnel connection failed"):
+		return &ClassifiedError{Class: ErrProxy, Message: msg}
+	case containsAny(lower, "500", "502", "503", "504", "timeout", "timed out", "connection reset", "temporary failure"):
+		return &ClassifiedError{Class: ErrTransientHTTP, Message: msg}
+	case containsAny(lower, "no video", "not found", "no matches", "no results", "unable to find",
+		"no suitable", "this video is not available", "video unavailable", "private video", "deleted video"):
+		return &ClassifiedError{Class: ErrNotFound, Message: msg}
+	case msg != "":
+		return &ClassifiedError{Class: ErrUnknown, Message: msg}
+	default:
+		return &ClassifiedError{Class: ErrUnknown, Message: "unknown error occurred"}
+	}
+}
+
+func containsAny(haystack string, needles ...string) bool {
+	for _, n := range needles {
+		if strings.Contains(haystack, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimitBreaker is a shared circuit breaker gated by a sync.Cond: once a
+// worker observes an HTTP 429 it trips the breaker, every worker blocks
+// until the cooldown elapses, and the trip optionally forces proxy
+// rotation on the next attempt across the whole pool.
+type RateLimitBreaker struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	tripped     bool
+	cooldown    time.Duration
+	forceRotate bool
+}
+
+// NewRateLimitBreaker creates a breaker with the given cooldown applied each
+// time it trips.
+func NewRateLimitBreaker(cooldown time.Duration) *RateLimitBreaker {
+	b := &RateLimitBreaker{cooldown: cooldown}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Trip puts every worker waiting on Wait to sleep for the cooldown period
+// and marks that the next attempt by each worker should rotate proxies.
+func (b *RateLimitBreaker) Trip() {
+	b.mu.Lock()
+	if b.tripped {
+		b.mu.Unlock()
+		return
+	}
+	b.tripped = true
+	b.forceRotate = true
+	b.mu.Unlock()
+
+	go func() {
+		time.Sleep(b.cooldown)
+		b.mu.Lock()
+		b.tripped = false
+		b.mu.Unlock()
+		b.cond.Broadcast()
+	}()
+}
+
+// Wait blocks the calling worker while the breaker is tripped and reports
+// whether the caller should rotate its proxy before the next attempt.
+func (b *RateLimitBreaker) Wait() (shouldRotate bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.tripped {
+		b.cond.Wait()
+	}
+	shouldRotate = b.forceRotate
+	b.forceRotate = false
+	return shouldRotate
+}
+
+// FailureCounters tallies how many attempts landed in each FailureClass, so
+// the final summary can report e.g. "12 rate limited, 3 copyright blocked"
+// instead of one opaque "failed" count.
+type FailureCounters struct {
+	mu     sync.Mutex
+	counts map[FailureClass]int
+}
+
+func NewFailureCounters() *FailureCounters {
+	return &FailureCounters{counts: make(map[FailureClass]int)}
+}
+
+func (f *FailureCounters) Record(class FailureClass) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts[class]++
+}
+
+// Summary returns a stable, human-readable "class: count" report for every
+// class that occurred at least once.
+func (f *FailureCounters) Summary() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.counts) == 0 {
+		return ""
+	}
+	classes := []FailureClass{
+		ErrRateLimited, ErrGeoBlocked, ErrAgeRestricted, ErrCopyrightBlocked,
+		ErrDNS, ErrProxy, ErrTransientHTTP, ErrPermanentHTTP, ErrNotFound, ErrUnknown,
+	}
+	var parts []string
+	for _, c := range classes {
+		if n := f.counts[c]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", c, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
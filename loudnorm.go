@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultTargetLUFS is the EBU R128 integrated loudness target used when a
+// LoudnessNormalizer isn't given an explicit one.
+const defaultTargetLUFS = -14.0
+
+// LoudnessNormalizer runs ffmpeg's loudnorm filter as a two-pass EBU R128
+// normalization: the first pass measures the file's actual loudness, and
+// the second pass feeds those measurements back in (linear=true) so the
+// correction is accurate instead of loudnorm's single-pass dynamic guess.
+type LoudnessNormalizer struct {
+	TargetLUFS float64 // Integrated loudness target in LUFS, e.g. -14
+}
+
+// NewLoudnessNormalizer builds a normalizer targeting defaultTargetLUFS.
+func NewLoudnessNormalizer() *LoudnessNormalizer {
+	return &LoudnessNormalizer{TargetLUFS: defaultTargetLUFS}
+}
+
+// loudnormMeasurement is the JSON block ffmpeg's loudnorm filter prints to
+// stderr with print_format=json on the measuring pass.
+type loudnormMeasurement struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+func (n *LoudnessNormalizer) Process(ctx context.Context, tf *TrackFile) error {
+	target := n.TargetLUFS
+	if target == 0 {
+		target = defaultTargetLUFS
+	}
+
+	measurement, err := n.measure(ctx, tf.Path, target)
+	if err != nil {
+		return fmt.Errorf("measuring loudness of %s: %w", tf.Path, err)
+	}
+
+	// ffmpeg picks its output muxer from the extension, so outPath has to
+	// keep the real one (.tmp isn't a registered muxer and fails every time).
+	ext := filepath.Ext(tf.Path)
+	outPath := strings.TrimSuffix(tf.Path, ext) + ".loudnorm" + ext
+	if err := n.apply(ctx, tf.Path, outPath, target, measurement); err != nil {
+		os.Remove(outPath)
+		return fmt.Errorf("normalizing loudness of %s: %w", tf.Path, err)
+	}
+
+	return os.Rename(outPath, tf.Path)
+}
+
+// measure runs the analysis pass and parses the JSON block loudnorm writes
+// to stderr.
+func (n *LoudnessNormalizer) measure(ctx context.Context, path string, target float64) (*loudnormMeasurement, error) {
+	filter := fmt.Sprintf("loudnorm=I=%s:TP=-1.5:LRA=11:print_format=json", formatLUFS(target))
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-i", path, "-af", filter, "-f", "null", "-")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	_ = cmd.Run() // loudnorm's analysis pass always exits non-zero writing to "-f null"; the JSON in stderr is what matters
+
+	var measurement loudnormMeasurement
+	if err := json.Unmarshal(extractJSONObject(stderr.String()), &measurement); err != nil {
+		return nil, fmt.Errorf("parsing loudnorm measurement: %w", err)
+	}
+	return &measurement, nil
+}
+
+// apply runs the second pass, feeding the first pass's measurements back
+// into loudnorm via linear=true so the gain applied is a precise linear
+// correction instead of loudnorm's dynamic single-pass estimate.
+func (n *LoudnessNormalizer) apply(ctx context.Context, inPath, outPath string, target float64, m *loudnormMeasurement) error {
+	filter := fmt.Sprintf(
+		"loudnorm=I=%s:TP=-1.5:LRA=11:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true:print_format=summary",
+		formatLUFS(target), m.InputI, m.InputTP, m.InputLRA, m.InputThresh, m.TargetOffset,
+	)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-i", inPath, "-af", filter, outPath)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+func formatLUFS(lufs float64) string {
+	return strconv.FormatFloat(lufs, 'f', 1, 64)
+}
+
+// extractJSONObject returns the last {...} block in output, which is where
+// loudnorm's print_format=json writes its measurement among ffmpeg's other
+// stderr chatter.
+func extractJSONObject(output string) []byte {
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start == -1 || end == -1 || end < start {
+		return nil
+	}
+	return []byte(output[start : end+1])
+}
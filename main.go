@@ -2,13 +2,17 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 	"unicode/utf8"
 )
@@ -21,53 +25,115 @@ const (
 	// PROXY_URL = ""                         // No proxy (direct connection)
 	// PROXY_URL = "http://your-proxy:8080"   // Custom proxy
 	PROXY_URL = "http://localhost:8881"
+
+	// stateStorePath is where per-track outcomes are recorded across runs.
+	stateStorePath = "vk-download-state.json"
+
+	// audioCodec and audioBitrate mirror the --audio-format/--audio-quality
+	// flags passed to yt-dlp, recorded into TrackState since yt-dlp itself
+	// doesn't report them back on the progress/meta lines we parse.
+	audioCodec   = "mp3"
+	audioBitrate = "192K"
 )
 
 // Track represents a music track with artist, title, and raw string
 type Track struct {
-	Artist string // Artist name
-	Title  string // Song title
-	Raw    string // Original string from playlist file
+	Artist   string        // Artist name
+	Title    string        // Song title
+	Album    string        // Album name, if the source provided one
+	Duration time.Duration // Track length, if the source provided one
+	Raw      string        // Original string from playlist file
 }
 
-// FailureReason represents the reason why a track failed to download
-type FailureReason int
-
-const (
-	NetworkError FailureReason = iota // Network/proxy issues
-	NotFound                          // Track not found on YouTube
-	UnknownError                      // Other errors
-)
-
 // Downloader handles concurrent downloading of music tracks
 type Downloader struct {
-	workers          int            // Number of concurrent workers
-	downloaded       int            // Count of successfully downloaded tracks
-	skipped          int            // Count of skipped tracks (already exist)
-	failed           int            // Count of failed downloads
-	mutex            sync.RWMutex   // Mutex for thread-safe counter updates
-	retryCount       int            // Number of retry attempts for failed downloads
-	skipExists       bool           // Whether to skip existing files
-	proxy            string         // Proxy URL (empty string = no proxy)
-	totalTracks      int            // Total number of tracks to download
-	failedTracksChan chan Track     // Channel to send failed tracks for immediate saving
-	saveWg           sync.WaitGroup // WaitGroup for the saving goroutine
+	workers          int                   // Number of concurrent workers
+	downloaded       int                   // Count of successfully downloaded tracks
+	skipped          int                   // Count of skipped tracks (already exist)
+	failed           int                   // Count of failed downloads
+	mutex            sync.RWMutex          // Mutex for thread-safe counter updates
+	skipExists       bool                  // Whether to skip existing files
+	proxyPool        *ProxyPool            // Pool of proxies to rotate through (always has at least a "direct" entry)
+	totalTracks      int                   // Total number of tracks to download
+	failedTracksChan chan Track            // Channel to send failed tracks for immediate saving
+	saveWg           sync.WaitGroup        // WaitGroup for the saving goroutine
+	breaker          *RateLimitBreaker     // Shared 429 cooldown across all workers
+	failureCounts    *FailureCounters      // Per-FailureClass tally surfaced in the final summary
+	noTTY            bool                  // Force PlainRenderer even when stdout is a TTY
+	progressCh       chan ProgressEvent    // Structured progress events consumed by the active ProgressRenderer
+	stateStore       *StateStore           // Records per-track outcomes across runs; nil disables resumability
+	force            bool                  // Ignore stateStore and re-attempt every track
+	retryFailedOnly  map[FailureClass]bool // If non-empty, only attempt tracks last recorded with one of these classes
+	processorChain   *ProcessorChain       // Post-processing run on every successful download; nil skips post-processing
 }
 
 // NewDownloader creates a new downloader with specified number of workers
 func NewDownloader(workers int) *Downloader {
 	return &Downloader{
 		workers:          workers,
-		retryCount:       2,                // Retry failed downloads up to 2 times
-		skipExists:       true,             // Skip files that already exist
-		proxy:            PROXY_URL,        // Use configured proxy (empty string = no proxy)
+		skipExists:       true, // Skip files that already exist
+		proxyPool:        loadProxyPoolFromEnv(),
+		stateStore:       LoadStateStore(stateStorePath),
 		failedTracksChan: make(chan Track), // Initialize the channel
+		breaker:          NewRateLimitBreaker(30 * time.Second),
+		failureCounts:    NewFailureCounters(),
+	}
+}
+
+// SetNoTTY forces the PlainRenderer even when stdout is a terminal.
+func (d *Downloader) SetNoTTY(noTTY bool) {
+	d.noTTY = noTTY
+}
+
+// SetForce makes Download/DownloadStream ignore the state store and
+// re-attempt every track, as if no prior run had happened.
+func (d *Downloader) SetForce(force bool) {
+	d.force = force
+}
+
+// SetRetryFailedOnly restricts attempts to tracks whose state store record
+// has a LastClass in classes, skipping everything else (including tracks
+// with no record at all). A nil or empty map disables the restriction.
+func (d *Downloader) SetRetryFailedOnly(classes map[FailureClass]bool) {
+	d.retryFailedOnly = classes
+}
+
+// SetProcessorChain sets the post-processing pipeline run on every
+// successful download, before the track is declared done. A nil chain (the
+// default) skips post-processing entirely.
+func (d *Downloader) SetProcessorChain(chain *ProcessorChain) {
+	d.processorChain = chain
+}
+
+// shouldAttempt decides, given the state store and this run's flags,
+// whether track should be dispatched to a worker at all.
+func (d *Downloader) shouldAttempt(track Track) bool {
+	if d.stateStore == nil {
+		return true
+	}
+	if len(d.retryFailedOnly) > 0 {
+		state, ok := d.stateStore.Get(track)
+		return ok && d.retryFailedOnly[state.LastClass]
 	}
+	if d.force {
+		return true
+	}
+	return !d.stateStore.ShouldSkip(track)
 }
 
-// SetProxy sets the proxy URL for downloads (empty string disables proxy)
-func (d *Downloader) SetProxy(proxyURL string) {
-	d.proxy = proxyURL
+// recordStateSkip tallies and announces a track skipped by the state store
+// (as opposed to the filesystem-stat skip inside downloadTrack). Reported
+// through the progress channel like every other skip/done/failed path,
+// instead of printing directly, since this runs concurrently with whatever
+// ProgressRenderer is managing the terminal.
+func (d *Downloader) recordStateSkip(track Track) {
+	d.mutex.Lock()
+	d.skipped++
+	count := d.downloaded + d.skipped + d.failed
+	total := d.totalTracks
+	d.mutex.Unlock()
+
+	d.emitProgress(ProgressEvent{Track: track, Stage: StageStateSkipped, Count: count, Total: total})
 }
 
 // Download processes all tracks concurrently and returns failed tracks
@@ -77,6 +143,15 @@ func (d *Downloader) Download(tracks []Track, outputDir string) []Track {
 	// No longer need results channel as failures are streamed
 	var wg sync.WaitGroup // Use a local waitgroup for workers
 
+	d.progressCh = make(chan ProgressEvent, d.workers*2)
+	renderer := newProgressRenderer(d.workers, d.totalTracks, d.noTTY)
+	var rendererWg sync.WaitGroup
+	rendererWg.Add(1)
+	go func() {
+		defer rendererWg.Done()
+		renderer.Run(d.progressCh)
+	}()
+
 	// Start a goroutine to continuously save failed tracks
 	d.saveWg.Add(1)
 	go d.streamSaveFailedTracks("vk-playlist-failed.txt")
@@ -84,12 +159,18 @@ func (d *Downloader) Download(tracks []Track, outputDir string) []Track {
 	// Start worker goroutines
 	for w := 0; w < d.workers; w++ {
 		wg.Add(1)
-		go d.worker(jobs, outputDir, &wg)
+		go d.worker(w+1, jobs, outputDir, &wg)
 	}
 
-	// Send all tracks as jobs
+	// Send all tracks as jobs, consulting the state store first so a rerun
+	// doesn't burn a yt-dlp invocation on a track it already knows the
+	// answer to.
 	go func() {
 		for _, track := range tracks {
+			if !d.shouldAttempt(track) {
+				d.recordStateSkip(track)
+				continue
+			}
 			jobs <- track
 		}
 		close(jobs)
@@ -99,60 +180,168 @@ func (d *Downloader) Download(tracks []Track, outputDir string) []Track {
 	go func() {
 		wg.Wait()
 		close(d.failedTracksChan) // Signal that no more failed tracks will be sent
+		close(d.progressCh)
 	}()
 
 	// Wait for the failed tracks saving goroutine to finish
 	d.saveWg.Wait()
+	rendererWg.Wait()
 
 	return nil // Failed tracks are now saved directly, no return needed here
 }
 
-// worker processes tracks from jobs channel with retry logic
-func (d *Downloader) worker(jobs <-chan Track, outputDir string, wg *sync.WaitGroup) {
+// DownloadStream consumes tracks from ch as they arrive instead of a
+// pre-built slice, so a daemon-mode downloader fed by an
+// FSNotifyPlaylistSource can keep pulling jobs for as long as ch stays
+// open. It returns once ch is closed and every in-flight track has
+// finished.
+func (d *Downloader) DownloadStream(ch <-chan Track, outputDir string) {
+	var wg sync.WaitGroup
+
+	d.progressCh = make(chan ProgressEvent, d.workers*2)
+	renderer := newProgressRenderer(d.workers, 0, d.noTTY)
+	var rendererWg sync.WaitGroup
+	rendererWg.Add(1)
+	go func() {
+		defer rendererWg.Done()
+		renderer.Run(d.progressCh)
+	}()
+
+	d.saveWg.Add(1)
+	go d.streamSaveFailedTracks("vk-playlist-failed.txt")
+
+	jobs := make(chan Track)
+	for w := 0; w < d.workers; w++ {
+		wg.Add(1)
+		go d.worker(w+1, jobs, outputDir, &wg)
+	}
+
+	go func() {
+		for track := range ch {
+			d.mutex.Lock()
+			d.totalTracks++
+			d.mutex.Unlock()
+			if !d.shouldAttempt(track) {
+				d.recordStateSkip(track)
+				continue
+			}
+			jobs <- track
+		}
+		close(jobs)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(d.failedTracksChan)
+		close(d.progressCh)
+	}()
+
+	d.saveWg.Wait()
+	rendererWg.Wait()
+}
+
+// worker processes tracks from jobs channel, consulting the RetryPolicy for
+// whatever FailureClass the previous attempt returned instead of a fixed
+// retryCount with linear sleep.
+func (d *Downloader) worker(id int, jobs <-chan Track, outputDir string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for track := range jobs {
 		var result DownloadResult
-		// Try downloading with retries
-		for attempt := 0; attempt <= d.retryCount; attempt++ {
-			result = d.downloadTrack(track, outputDir)
+		var preferProxy *string // non-nil sticks the next attempt to this proxy; nil means "rotate freely"
+		clientIdx := 0          // bumped on RotateClient to cycle yt-dlp's extractor client
+
+		for attempt := 0; ; attempt++ {
+			result = d.downloadTrack(id, track, outputDir, preferProxy, clientIdx)
+			d.proxyPool.Report(result.Proxy, result)
 			if result.Success {
 				break
 			}
-			// Wait between retry attempts with exponential backoff
-			if attempt < d.retryCount {
-				time.Sleep(time.Second * time.Duration(attempt+1))
+
+			policy := policyFor(result.Class)
+			d.failureCounts.Record(result.Class)
+
+			if policy.GlobalCooldown {
+				d.breaker.Trip()
+			}
+			if attempt >= policy.MaxAttempts {
+				break
+			}
+
+			if policy.RotateProxy {
+				preferProxy = nil
+			} else {
+				usedProxy := result.Proxy
+				preferProxy = &usedProxy
+			}
+			if policy.RotateClient {
+				clientIdx++
+			}
+			if d.breaker.Wait() {
+				// The breaker observed a 429 on another worker since our
+				// last attempt; force a fresh proxy even if this class's
+				// own policy wouldn't have rotated one.
+				preferProxy = nil
+			}
+			if policy.Backoff != nil {
+				time.Sleep(policy.Backoff(attempt))
 			}
 		}
 
-		// Update counters and display progress (thread-safe)
+		d.recordState(track, result)
+
+		// Update counters and figure out what to report (thread-safe)
 		d.mutex.Lock()
+		stage := StageDone
 		if result.Success {
 			if result.Skipped {
 				d.skipped++
-				fmt.Printf("⏭️  [%d/%d] Already exists: %s\n", d.downloaded+d.skipped+d.failed, d.totalTracks, track.Raw)
+				stage = StageSkipped
 			} else {
 				d.downloaded++
-				fmt.Printf("✅ [%d/%d] Downloaded: %s\n", d.downloaded+d.skipped+d.failed, d.totalTracks, track.Raw)
 			}
 		} else {
 			d.failed++
-			reasonStr := ""
-			switch result.Reason {
-			case NetworkError:
-				reasonStr = "Network/proxy error"
-			case NotFound:
-				reasonStr = "Track not found"
-			case UnknownError:
-				reasonStr = "Unknown error"
-			}
-			fmt.Printf("❌ [%d/%d] Failed: %s [%s]\n", d.downloaded+d.skipped+d.failed, d.totalTracks, track.Raw, reasonStr)
-			d.failedTracksChan <- track // Send failed track to the channel
+			stage = StageFailed
 		}
+		count := d.downloaded + d.skipped + d.failed
+		total := d.totalTracks
 		d.mutex.Unlock()
+
+		// Report through the progress channel rather than printing directly,
+		// so the active ProgressRenderer decides how (or whether) to show it
+		// instead of this racing with e.g. TTYRenderer's managed bars.
+		d.emitProgress(ProgressEvent{Worker: id, Track: track, Stage: stage, Class: result.Class, Count: count, Total: total})
+		if !result.Success {
+			d.failedTracksChan <- track // Send failed track to the channel
+		}
 	}
 }
 
+// recordState updates the state store with the outcome of track's attempts
+// this run, so a later rerun can consult it via shouldAttempt. A no-op if
+// no state store is configured, or the result came from the filesystem-stat
+// skip (which didn't touch yt-dlp and so has nothing new to record).
+func (d *Downloader) recordState(track Track, result DownloadResult) {
+	if d.stateStore == nil || result.Skipped {
+		return
+	}
+
+	prior, _ := d.stateStore.Get(track)
+	d.stateStore.Record(track, TrackState{
+		Filename:    result.Filename,
+		Codec:       audioCodec,
+		Bitrate:     audioBitrate,
+		Duration:    track.Duration,
+		Extractor:   result.Extractor,
+		VideoID:     result.VideoID,
+		LastAttempt: time.Now(),
+		Attempts:    prior.Attempts + 1,
+		LastClass:   result.Class,
+		Success:     result.Success,
+	})
+}
+
 // streamSaveFailedTracks continuously writes failed tracks to the specified file
 func (d *Downloader) streamSaveFailedTracks(filename string) {
 	defer d.saveWg.Done()
@@ -175,14 +364,28 @@ func (d *Downloader) streamSaveFailedTracks(filename string) {
 
 // DownloadResult represents the result of a download attempt
 type DownloadResult struct {
-	Success bool
-	Skipped bool // True if file was skipped due to already existing
-	Reason  FailureReason
-	Message string
+	Success   bool
+	Skipped   bool // True if file was skipped due to already existing
+	Class     FailureClass
+	Message   string
+	Proxy     string // Proxy URL acquired for this attempt (empty = direct), for ProxyPool.Report
+	Filename  string // Final (non-.tmp) path, set on success, for the state store
+	Extractor string // yt-dlp extractor name parsed from MD-META, for the state store
+	VideoID   string // yt-dlp video id parsed from MD-META, for the state store
 }
 
-// downloadTrack downloads a single track using yt-dlp
-func (d *Downloader) downloadTrack(track Track, outputDir string) DownloadResult {
+// ytdlpPlayerClients is the rotation yt-dlp's youtube extractor cycles
+// through when a RetryPolicy asks for RotateClient: a search that came back
+// ErrNotFound against one client (age-gated, client-specific takedown, ...)
+// sometimes succeeds against another.
+var ytdlpPlayerClients = []string{"android", "ios", "web"}
+
+// downloadTrack downloads a single track using yt-dlp, emitting
+// ProgressEvents on d.progressCh as yt-dlp reports search/download progress
+// instead of printing directly, so the configured ProgressRenderer decides
+// how (or whether) to display them. clientIdx selects ytdlpPlayerClients[0]
+// on the first attempt and rotates on each RotateClient retry.
+func (d *Downloader) downloadTrack(worker int, track Track, outputDir string, preferProxy *string, clientIdx int) (result DownloadResult) {
 	safeName := sanitizeFilename(fmt.Sprintf("%s - %s", track.Artist, track.Title))
 
 	// Check if file already exists with any extension (excluding .tmp files)
@@ -209,12 +412,28 @@ func (d *Downloader) downloadTrack(track Track, outputDir string) DownloadResult
 		"--output", templatePath, // Output filename template with .tmp extension
 		"--no-playlist",        // Download single video only
 		"--max-downloads", "1", // Limit to first result
-		"--ignore-errors", // Continue on errors
+		"--ignore-errors",                       // Continue on errors
+		"--continue",                            // Resume a partial .tmp from its byte offset instead of restarting
+		"--write-info-json",                     // Sidecar JSON consumed by the ID3Tagger post-processor
+		"--newline",                             // One progress line per update instead of carriage-return overwrites
+		"--progress-template", progressTemplate, // Structured "MD-PROGRESS downloaded total eta" lines
+		"--print", metaTemplate, // Structured "MD-META extractor id" line once the match is known
+		"--extractor-args", fmt.Sprintf("youtube:player_client=%s", ytdlpPlayerClients[clientIdx%len(ytdlpPlayerClients)]),
+	}
+
+	// Acquire a proxy for this attempt, sticking with preferProxy when the
+	// caller's RetryPolicy didn't call for rotation. Report below feeds the
+	// outcome back into the pool's health scoring so a failing proxy gets
+	// parked instead of being hammered on the next retry.
+	var acquiredProxy string
+	if preferProxy != nil {
+		acquiredProxy = d.proxyPool.AcquirePreferring(*preferProxy)
+	} else {
+		acquiredProxy = d.proxyPool.Acquire()
 	}
-
-	// Add proxy if configured
-	if d.proxy != "" {
-		args = append(args, "--proxy", d.proxy)
+	defer func() { result.Proxy = acquiredProxy }()
+	if acquiredProxy != "" {
+		args = append(args, "--proxy", acquiredProxy)
 	}
 
 	// Add search query as final argument
@@ -227,7 +446,7 @@ func (d *Downloader) downloadTrack(track Track, outputDir string) DownloadResult
 	allArgs := append(ytDlpCmd[1:], args...)
 
 	// Log download attempt
-	fmt.Printf("🔍 Searching: %s\n", query)
+	d.emitProgress(ProgressEvent{Worker: worker, Track: track, Stage: StageSearching})
 
 	// Execute yt-dlp command and capture output in real-time
 	cmd := exec.Command(ytDlpCmd[0], allArgs...)
@@ -235,68 +454,59 @@ func (d *Downloader) downloadTrack(track Track, outputDir string) DownloadResult
 	// Set up pipes for real-time output monitoring
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return DownloadResult{Success: false, Reason: UnknownError, Message: fmt.Sprintf("Failed to create stdout pipe: %v", err)}
+		return DownloadResult{Success: false, Class: ErrUnknown, Message: fmt.Sprintf("Failed to create stdout pipe: %v", err)}
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return DownloadResult{Success: false, Reason: UnknownError, Message: fmt.Sprintf("Failed to create stderr pipe: %v", err)}
+		return DownloadResult{Success: false, Class: ErrUnknown, Message: fmt.Sprintf("Failed to create stderr pipe: %v", err)}
 	}
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
-		return DownloadResult{Success: false, Reason: UnknownError, Message: fmt.Sprintf("Failed to start command: %v", err)}
+		return DownloadResult{Success: false, Class: ErrUnknown, Message: fmt.Sprintf("Failed to start command: %v", err)}
 	}
 
 	// Monitor stdout for progress updates
 	var stdoutBuilder, stderrBuilder strings.Builder
-	var foundShown, downloadingShown bool
+	var extractor, videoID string
+	var wg sync.WaitGroup
+	wg.Add(2)
 
-	// Read stdout in real-time
+	// Read stdout in real-time, translating MD-PROGRESS lines into
+	// ProgressEvents and capturing the MD-META line instead of printing
+	// either directly.
 	go func() {
+		defer wg.Done()
 		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
 			line := scanner.Text()
 			stdoutBuilder.WriteString(line + "\n")
 
-			// Check if track was found and show message immediately
-			if !foundShown && (strings.Contains(line, "Downloading") || strings.Contains(line, "Extracting") ||
-				strings.Contains(line, "[youtube]") || strings.Contains(line, "has already been downloaded")) {
-				fmt.Printf("📁 Found: %s\n", query)
-				foundShown = true
+			if downloaded, total, eta, ok := parseProgressLine(line); ok {
+				d.emitProgress(ProgressEvent{
+					Worker: worker, Track: track, Stage: StageDownloading,
+					BytesDone: downloaded, BytesTotal: total, ETA: eta,
+				})
 			}
-
-			// Show downloading progress only once
-			if !downloadingShown && strings.Contains(line, "Downloading") && !strings.Contains(line, "Downloading webpage") {
-				fmt.Printf("⬇️  Downloading: %s\n", query)
-				downloadingShown = true
+			if e, id, ok := parseMetaLine(line); ok {
+				extractor, videoID = e, id
 			}
 		}
 	}()
 
 	// Read stderr
 	go func() {
+		defer wg.Done()
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
-			line := scanner.Text()
-			stderrBuilder.WriteString(line + "\n")
-
-			// Also check stderr for progress messages
-			if !foundShown && (strings.Contains(line, "Downloading") || strings.Contains(line, "Extracting") ||
-				strings.Contains(line, "[youtube]") || strings.Contains(line, "has already been downloaded")) {
-				fmt.Printf("📁 Found: %s\n", query)
-				foundShown = true
-			}
-
-			if !downloadingShown && strings.Contains(line, "Downloading") && !strings.Contains(line, "Downloading webpage") {
-				fmt.Printf("⬇️  Downloading: %s\n", query)
-				downloadingShown = true
-			}
+			stderrBuilder.WriteString(scanner.Text() + "\n")
 		}
 	}()
 
 	// Wait for command to finish
 	cmdErr := cmd.Wait()
+	wg.Wait()
 
 	// Check if any temporary audio file was downloaded and rename it
 	extensions := []string{".mp3", ".webm", ".m4a", ".ogg", ".opus"}
@@ -308,55 +518,48 @@ func (d *Downloader) downloadTrack(track Track, outputDir string) DownloadResult
 			if err := os.Rename(tempPath, finalPath); err != nil {
 				// If rename fails, remove temp file and return error
 				os.Remove(tempPath)
-				return DownloadResult{Success: false, Reason: UnknownError, Message: fmt.Sprintf("Failed to rename temp file: %v", err)}
+				return DownloadResult{Success: false, Class: ErrUnknown, Message: fmt.Sprintf("Failed to rename temp file: %v", err)}
+			}
+			if d.processorChain != nil {
+				tf := &TrackFile{
+					Path:         finalPath,
+					Track:        track,
+					Extractor:    extractor,
+					VideoID:      videoID,
+					InfoJSONPath: infoJSONPath(finalPath),
+				}
+				if err := d.processorChain.Run(context.Background(), tf); err != nil {
+					return DownloadResult{Success: false, Class: ErrUnknown, Message: fmt.Sprintf("post-processing: %v", err)}
+				}
 			}
-			return DownloadResult{Success: true, Message: "Downloaded successfully"}
-		}
-	}
-
-	// Analyze failure reason
-	errorOutput := stderrBuilder.String()
-	reason, message := analyzeFailure(errorOutput, cmdErr)
-
-	return DownloadResult{Success: false, Reason: reason, Message: message}
-}
-
-// analyzeFailure analyzes yt-dlp error output to determine failure reason
-func analyzeFailure(errorOutput string, cmdErr error) (FailureReason, string) {
-	errorLower := strings.ToLower(errorOutput)
-
-	// Check for network-related errors
-	networkKeywords := []string{
-		"connection", "proxy", "timeout", "network", "dns", "ssl", "tls", "certificate",
-		"host", "refused", "unreachable", "blocked", "403", "503", "502", "500",
-		"unable to download", "httperror", "urlerror", "no such host",
-	}
 
-	for _, keyword := range networkKeywords {
-		if strings.Contains(errorLower, keyword) {
-			return NetworkError, fmt.Sprintf("Network error: %s", strings.TrimSpace(errorOutput))
+			return DownloadResult{
+				Success: true, Message: "Downloaded successfully",
+				Filename: finalPath, Extractor: extractor, VideoID: videoID,
+			}
 		}
 	}
 
-	// Check for "not found" errors
-	notFoundKeywords := []string{
-		"no video", "not found", "no matches", "no results", "unable to find",
-		"no suitable", "this video is not available", "video unavailable",
-		"private video", "deleted video", "age-restricted",
-	}
+	// Classify the failure so the worker loop's RetryPolicy knows how to react
+	classified := classifyFailure(stderrBuilder.String(), cmdErr)
 
-	for _, keyword := range notFoundKeywords {
-		if strings.Contains(errorLower, keyword) {
-			return NotFound, fmt.Sprintf("Track not found: %s", strings.TrimSpace(errorOutput))
-		}
-	}
+	return DownloadResult{Success: false, Class: classified.Class, Message: classified.Message}
+}
 
-	// Default to unknown error
-	if errorOutput != "" {
-		return UnknownError, fmt.Sprintf("Unknown error: %s", strings.TrimSpace(errorOutput))
+// emitProgress sends ev to the progress channel if one is configured,
+// without blocking callers when no ProgressRenderer is attached (e.g. in
+// tests that exercise downloadTrack directly).
+func (d *Downloader) emitProgress(ev ProgressEvent) {
+	if d.progressCh != nil {
+		d.progressCh <- ev
 	}
+}
 
-	return UnknownError, "Unknown error occurred"
+// infoJSONPath returns where --write-info-json leaves its sidecar for a
+// track whose final audio file is at finalPath: same basename, ".info.json"
+// in place of the audio extension.
+func infoJSONPath(finalPath string) string {
+	return strings.TrimSuffix(finalPath, filepath.Ext(finalPath)) + ".info.json"
 }
 
 // sanitizeFilename removes invalid characters from filename and limits length
@@ -380,46 +583,6 @@ func sanitizeFilename(name string) string {
 	return result
 }
 
-// readPlaylist reads and parses the playlist file, removing duplicates
-func readPlaylist(filename string) ([]Track, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var tracks []Track
-	seen := make(map[string]bool) // Track duplicates
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Skip empty lines and duplicates
-		if line == "" || seen[line] {
-			continue
-		}
-		seen[line] = true
-
-		// Parse "Artist - Title" format
-		parts := strings.Split(line, " - ")
-		if len(parts) < 2 {
-			continue // Skip malformed lines
-		}
-
-		artist := strings.TrimSpace(parts[0])
-		// Handle titles with multiple " - " separators
-		title := strings.TrimSpace(strings.Join(parts[1:], " - "))
-
-		tracks = append(tracks, Track{
-			Artist: artist,
-			Title:  title,
-			Raw:    line,
-		})
-	}
-
-	return tracks, scanner.Err()
-}
-
 // saveFailedTracks writes failed tracks to a file
 func saveFailedTracks(failed []Track, filename string) error {
 	if len(failed) == 0 {
@@ -488,6 +651,20 @@ func cleanupTempFiles(outputDir string) {
 
 // main function orchestrates the entire download process
 func main() {
+	noTTY := flag.Bool("no-tty", false, "Use plain line-per-event output instead of live progress bars")
+	force := flag.Bool("force", false, "Ignore the state store and re-attempt every track")
+	retryFailed := flag.String("retry-failed", "", "Comma-separated FailureClass names (e.g. \"dns,proxy\"); only re-attempt tracks last recorded with one of these")
+	coverArt := flag.Bool("cover-art", false, "Embed cover art fetched from the thumbnail or MusicBrainz/Cover Art Archive")
+	normalize := flag.Bool("normalize", false, "Two-pass ffmpeg loudnorm to -14 LUFS after download")
+	s3Bucket := flag.String("upload-s3-bucket", "", "If set, upload finished files to this S3 bucket (credentials via the normal AWS SDK chain)")
+	s3Prefix := flag.String("upload-s3-prefix", "", "Key prefix for --upload-s3-bucket uploads")
+	webdavURL := flag.String("upload-webdav-url", "", "If set, PUT finished files to this WebDAV base URL")
+	webdavUser := flag.String("upload-webdav-user", "", "Basic auth username for --upload-webdav-url")
+	webdavPass := flag.String("upload-webdav-pass", "", "Basic auth password for --upload-webdav-url")
+	deleteAfterUpload := flag.Bool("delete-after-upload", false, "Remove the local file once an upload sink accepts it")
+	watchDir := flag.String("watch", "", "Watch this directory for dropped/updated playlist files and run as a long-lived daemon instead of a one-shot pass")
+	flag.Parse()
+
 	// Verify yt-dlp is installed
 	if !checkYtDlp() {
 		log.Fatal("yt-dlp not found. Please install it: pip install yt-dlp")
@@ -499,39 +676,118 @@ func main() {
 		log.Fatal("Failed to create music directory:", err)
 	}
 
-	// Clean up any incomplete downloads from previous runs
-	cleanupTempFiles(outputDir)
+	// Only blow away incomplete .tmp files when --force is set: yt-dlp's
+	// --continue resumes a partial .tmp from its byte offset, so leaving
+	// them in place on a normal rerun is what makes that resumability work.
+	if *force {
+		cleanupTempFiles(outputDir)
+	}
 
-	// Read and parse playlist
-	tracks, err := readPlaylist("vk-playlist.txt")
+	// Start concurrent download process
+	downloader := NewDownloader(4) // 4 concurrent downloads
+	downloader.SetNoTTY(*noTTY)
+	downloader.SetForce(*force)
+	if *retryFailed != "" {
+		downloader.SetRetryFailedOnly(parseFailureClasses(*retryFailed))
+	}
+
+	chain, err := buildProcessorChain(processorChainOptions{
+		CoverArt:          *coverArt,
+		Normalize:         *normalize,
+		S3Bucket:          *s3Bucket,
+		S3Prefix:          *s3Prefix,
+		WebDAVURL:         *webdavURL,
+		WebDAVUser:        *webdavUser,
+		WebDAVPass:        *webdavPass,
+		DeleteAfterUpload: *deleteAfterUpload,
+	})
+	if err != nil {
+		log.Fatal("Failed to build post-processing chain:", err)
+	}
+	downloader.SetProcessorChain(chain)
+
+	// Probe every proxy before the first track is dispatched so a dead one
+	// isn't discovered only after burning a download attempt on it.
+	probeCtx, cancelProbe := context.WithTimeout(context.Background(), 30*time.Second)
+	downloader.proxyPool.Probe(probeCtx, "https://www.youtube.com")
+	cancelProbe()
+
+	if *watchDir != "" {
+		runDaemon(downloader, *watchDir, outputDir)
+	} else {
+		runOnce(downloader, outputDir)
+	}
+}
+
+// runOnce reads vk-playlist.txt, runs every track through downloader once,
+// and exits. This is the normal, non-daemon mode of operation.
+func runOnce(downloader *Downloader, outputDir string) {
+	playlistPath := "vk-playlist.txt"
+	source, err := newPlaylistSource(playlistPath)
+	if err != nil {
+		log.Fatal("Failed to load playlist source:", err)
+	}
+	tracks, err := source.Tracks()
 	if err != nil {
 		log.Fatal("Failed to read playlist:", err)
 	}
 
 	fmt.Printf("Found %d unique tracks\n", len(tracks))
 
-	// Start concurrent download process
-	downloader := NewDownloader(4) // 4 concurrent downloads
+	downloader.Download(tracks, outputDir)
 
-	// Display proxy status
-	if downloader.proxy != "" {
-		fmt.Printf("Using proxy: %s\n", downloader.proxy)
-	} else {
-		fmt.Printf("Direct connection (no proxy)\n")
+	if err := downloader.stateStore.Save(); err != nil {
+		log.Printf("Failed to save state store: %v", err)
 	}
 
-	downloader.Download(tracks, outputDir)
+	printDownloadSummary("Download completed", downloader)
+}
 
-	// Display final statistics
-	fmt.Printf("\nDownload completed:\n")
+// runDaemon watches watchDir for dropped/updated playlist files via
+// FSNotifyPlaylistSource and feeds their tracks to downloader as they
+// arrive, via DownloadStream, until interrupted. SIGINT/SIGTERM close the
+// watcher so DownloadStream returns and the state store still gets saved,
+// instead of Ctrl-C killing the process mid-download and losing whatever
+// this session downloaded.
+func runDaemon(downloader *Downloader, watchDir, outputDir string) {
+	source, err := NewFSNotifyPlaylistSource(watchDir)
+	if err != nil {
+		log.Fatal("Failed to watch directory:", err)
+	}
+	defer source.Close()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		fmt.Println("\nStopping watch, finishing in-flight downloads...")
+		source.Close()
+	}()
+
+	go func() {
+		for err := range source.Errors {
+			log.Printf("playlist watch error: %v", err)
+		}
+	}()
+
+	fmt.Printf("Watching %s for dropped playlists (Ctrl-C to stop)...\n", watchDir)
+	downloader.DownloadStream(source.Events, outputDir)
+
+	if err := downloader.stateStore.Save(); err != nil {
+		log.Printf("Failed to save state store: %v", err)
+	}
+
+	printDownloadSummary("Watch stopped", downloader)
+}
+
+// printDownloadSummary prints the ✅/⏭️/❌ tallies shared by runOnce and
+// runDaemon under heading.
+func printDownloadSummary(heading string, downloader *Downloader) {
+	fmt.Printf("\n%s:\n", heading)
 	fmt.Printf("✅ Downloaded: %d\n", downloader.downloaded)
 	fmt.Printf("⏭️  Skipped (already existed): %d\n", downloader.skipped)
 	fmt.Printf("❌ Failed: %d\n", downloader.failed)
-
-	// No longer call saveFailedTracks here, it's streamed
-	// if len(failed) > 0 {
-	// 	if err := saveFailedTracks(failed, "vk-playlist-failed.txt"); err != nil {
-	// 		log.Printf("Failed to save failed tracks: %v", err)
-	// 	}
-	// }
+	if summary := downloader.failureCounts.Summary(); summary != "" {
+		fmt.Printf("   By class: %s\n", summary)
+	}
 }
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// PlaylistSource abstracts where tracks come from: a plain text list, an
+// M3U/PLS playlist exported by another player, a JSON manifest, or a
+// directory that keeps receiving new playlist files.
+type PlaylistSource interface {
+	Tracks() ([]Track, error)
+}
+
+// newPlaylistSource sniffs the file extension of filename and returns the
+// PlaylistSource implementation that understands it. Unknown extensions
+// fall back to the original "Artist - Title" text format.
+func newPlaylistSource(filename string) (PlaylistSource, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m3u", ".m3u8":
+		return &M3UPlaylistSource{path: filename}, nil
+	case ".pls":
+		return &PLSPlaylistSource{path: filename}, nil
+	case ".json":
+		return &JSONPlaylistSource{path: filename}, nil
+	default:
+		return &TextPlaylistSource{path: filename}, nil
+	}
+}
+
+// TextPlaylistSource reads the hand-rolled "Artist - Title" per line format
+// that the downloader has always accepted, skipping blanks and duplicates.
+type TextPlaylistSource struct {
+	path string
+}
+
+func (s *TextPlaylistSource) Tracks() ([]Track, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tracks []Track
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+
+		parts := strings.Split(line, " - ")
+		if len(parts) < 2 {
+			continue // Skip malformed lines
+		}
+
+		artist := strings.TrimSpace(parts[0])
+		title := strings.TrimSpace(strings.Join(parts[1:], " - "))
+
+		tracks = append(tracks, Track{Artist: artist, Title: title, Raw: line})
+	}
+
+	return tracks, scanner.Err()
+}
+
+// extinfRe pulls the duration and "Artist - Title" label out of an
+// #EXTINF:<duration>,<label> line.
+var extinfRe = regexp.MustCompile(`^#EXTINF:\s*(-?\d+)\s*,\s*(.*)$`)
+
+// M3UPlaylistSource parses M3U/M3U8 playlists, pairing #EXTINF metadata
+// (duration + "Artist - Title" label) with the entry path that follows it.
+type M3UPlaylistSource struct {
+	path string
+}
+
+func (s *M3UPlaylistSource) Tracks() ([]Track, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tracks []Track
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+
+	var pendingLabel string
+	var pendingDuration int
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if m := extinfRe.FindStringSubmatch(line); m != nil {
+			pendingDuration, _ = strconv.Atoi(m[1])
+			pendingLabel = strings.TrimSpace(m[2])
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			continue // Other M3U directives we don't care about
+		}
+
+		// line is the media entry; prefer the EXTINF label if we have one
+		label := pendingLabel
+		if label == "" {
+			label = strings.TrimSuffix(filepath.Base(line), filepath.Ext(line))
+		}
+		pendingLabel = ""
+
+		if seen[label] {
+			pendingDuration = 0
+			continue
+		}
+		seen[label] = true
+
+		artist, title := splitArtistTitle(label)
+		tracks = append(tracks, Track{
+			Artist:   artist,
+			Title:    title,
+			Raw:      label,
+			Duration: time.Duration(pendingDuration) * time.Second,
+		})
+		pendingDuration = 0
+	}
+
+	return tracks, scanner.Err()
+}
+
+// PLSPlaylistSource parses the INI-style .pls format (FileN/TitleN/LengthN
+// triplets under a [playlist] section).
+type PLSPlaylistSource struct {
+	path string
+}
+
+func (s *PLSPlaylistSource) Tracks() ([]Track, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	titles := make(map[int]string)
+	lengths := make(map[int]int)
+	files := make(map[int]string)
+	var indices []int
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(key, "Title"):
+			idx, err := strconv.Atoi(strings.TrimPrefix(key, "Title"))
+			if err != nil {
+				continue
+			}
+			titles[idx] = value
+			indices = appendUnique(indices, idx)
+		case strings.HasPrefix(key, "Length"):
+			idx, err := strconv.Atoi(strings.TrimPrefix(key, "Length"))
+			if err != nil {
+				continue
+			}
+			seconds, _ := strconv.Atoi(value)
+			lengths[idx] = seconds
+		case strings.HasPrefix(key, "File"):
+			idx, err := strconv.Atoi(strings.TrimPrefix(key, "File"))
+			if err != nil {
+				continue
+			}
+			files[idx] = value
+			indices = appendUnique(indices, idx)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var tracks []Track
+	seen := make(map[string]bool)
+	for _, idx := range indices {
+		label := titles[idx]
+		if label == "" {
+			if f := files[idx]; f != "" {
+				label = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+			}
+		}
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+
+		artist, title := splitArtistTitle(label)
+		tracks = append(tracks, Track{
+			Artist:   artist,
+			Title:    title,
+			Raw:      label,
+			Duration: time.Duration(lengths[idx]) * time.Second,
+		})
+	}
+
+	return tracks, nil
+}
+
+// jsonTrack mirrors the [{"artist":...,"title":...,"album":...,"duration":...}]
+// schema accepted by JSONPlaylistSource. Duration is in seconds.
+type jsonTrack struct {
+	Artist   string `json:"artist"`
+	Title    string `json:"title"`
+	Album    string `json:"album"`
+	Duration int    `json:"duration"`
+}
+
+// JSONPlaylistSource parses a playlist expressed as a JSON array of
+// artist/title/album/duration objects.
+type JSONPlaylistSource struct {
+	path string
+}
+
+func (s *JSONPlaylistSource) Tracks() ([]Track, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []jsonTrack
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %s as JSON playlist: %w", s.path, err)
+	}
+
+	var tracks []Track
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		artist := strings.TrimSpace(e.Artist)
+		title := strings.TrimSpace(e.Title)
+		if artist == "" || title == "" {
+			continue
+		}
+		raw := fmt.Sprintf("%s - %s", artist, title)
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+
+		tracks = append(tracks, Track{
+			Artist:   artist,
+			Title:    title,
+			Album:    e.Album,
+			Raw:      raw,
+			Duration: time.Duration(e.Duration) * time.Second,
+		})
+	}
+
+	return tracks, nil
+}
+
+// FSNotifyPlaylistSource watches a directory for playlist files being added
+// or rewritten and emits their tracks over Events as they appear, so the
+// downloader can run as a long-lived daemon ingesting dropped playlists
+// instead of exiting after one pass.
+type FSNotifyPlaylistSource struct {
+	dir     string
+	watcher *fsnotify.Watcher
+	Events  chan Track
+	Errors  chan error
+}
+
+// NewFSNotifyPlaylistSource starts watching dir and returns a source whose
+// Events channel receives tracks as playlist files are created or modified.
+// Call Close when done to stop the underlying watcher.
+func NewFSNotifyPlaylistSource(dir string) (*FSNotifyPlaylistSource, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("creating fsnotify watcher: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("watching %s: %w", dir, err)
+	}
+
+	source := &FSNotifyPlaylistSource{
+		dir:     dir,
+		watcher: watcher,
+		Events:  make(chan Track, 32),
+		Errors:  make(chan error, 8),
+	}
+	go source.run()
+	return source, nil
+}
+
+func (s *FSNotifyPlaylistSource) run() {
+	// Close both channels on every exit path, not just the one that
+	// happened to be selected: watcher.Close() closes Events and Errors
+	// together, so whichever case the select notices first must still
+	// leave both of ours closed, or a caller ranging over Events blocks
+	// forever waiting for a close that only the other case would have sent.
+	defer close(s.Events)
+	defer close(s.Errors)
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			sub, err := newPlaylistSource(event.Name)
+			if err != nil {
+				s.Errors <- err
+				continue
+			}
+			tracks, err := sub.Tracks()
+			if err != nil {
+				s.Errors <- fmt.Errorf("parsing %s: %w", event.Name, err)
+				continue
+			}
+			for _, t := range tracks {
+				s.Events <- t
+			}
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.Errors <- err
+		}
+	}
+}
+
+// Close stops watching the directory and closes the underlying watcher.
+func (s *FSNotifyPlaylistSource) Close() error {
+	return s.watcher.Close()
+}
+
+// splitArtistTitle applies the same "Artist - Title" convention used by the
+// text format to a playlist label pulled from M3U/PLS metadata.
+func splitArtistTitle(label string) (artist, title string) {
+	parts := strings.Split(label, " - ")
+	if len(parts) < 2 {
+		return "", strings.TrimSpace(label)
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(strings.Join(parts[1:], " - "))
+}
+
+func appendUnique(indices []int, idx int) []int {
+	for _, existing := range indices {
+		if existing == idx {
+			return indices
+		}
+	}
+	return append(indices, idx)
+}
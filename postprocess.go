@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// processorChainOptions configures which post-processing stages
+// buildProcessorChain wires up. Zero value is "tag only": ID3Tagger runs,
+// everything else is skipped.
+type processorChainOptions struct {
+	CoverArt          bool
+	Normalize         bool
+	S3Bucket          string // Non-empty enables the S3 upload sink
+	S3Prefix          string
+	WebDAVURL         string // Non-empty enables the WebDAV upload sink
+	WebDAVUser        string
+	WebDAVPass        string
+	DeleteAfterUpload bool
+}
+
+// buildProcessorChain assembles the ProcessorChain described by opts.
+// ID3Tagger always runs; CoverArtEmbedder and LoudnessNormalizer are
+// WarnOnly since they enrich an already-successful download rather than
+// define it, so a flaky image host or missing ffmpeg shouldn't turn a good
+// download into a failure. Upload failures are not demoted: the user asked
+// for the file to land somewhere, and silently keeping it local instead
+// isn't the same outcome.
+func buildProcessorChain(opts processorChainOptions) (*ProcessorChain, error) {
+	stages := []ProcessorStage{
+		{Name: "id3-tag", Processor: &ID3Tagger{}},
+	}
+
+	if opts.CoverArt {
+		stages = append(stages, ProcessorStage{Name: "cover-art", Processor: NewCoverArtEmbedder(), WarnOnly: true})
+	}
+	if opts.Normalize {
+		stages = append(stages, ProcessorStage{Name: "loudnorm", Processor: NewLoudnessNormalizer(), WarnOnly: true})
+	}
+
+	// Upload stages never delete their own DeleteLocal right away: if both
+	// S3 and WebDAV are configured, deleting after the first would make the
+	// second stage's upload fail on its own success. DeleteLocal is applied
+	// only to the last upload stage, once every sink has had the file.
+	var uploadStages []ProcessorStage
+	if opts.S3Bucket != "" {
+		sink, err := NewS3Sink(context.Background(), opts.S3Bucket, opts.S3Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("building S3 upload sink: %w", err)
+		}
+		uploadStages = append(uploadStages, ProcessorStage{
+			Name:      "upload-s3",
+			Processor: &UploadProcessor{Sink: sink},
+		})
+	}
+	if opts.WebDAVURL != "" {
+		sink := NewWebDAVSink(opts.WebDAVURL, opts.WebDAVUser, opts.WebDAVPass)
+		uploadStages = append(uploadStages, ProcessorStage{
+			Name:      "upload-webdav",
+			Processor: &UploadProcessor{Sink: sink},
+		})
+	}
+	if n := len(uploadStages); n > 0 && opts.DeleteAfterUpload {
+		uploadStages[n-1].Processor.(*UploadProcessor).DeleteLocal = true
+	}
+	stages = append(stages, uploadStages...)
+
+	return NewProcessorChain(stages...), nil
+}
+
+// TrackFile is what a Processor operates on: the finished audio file plus
+// whatever the downloader already knows about the track that produced it.
+type TrackFile struct {
+	Path         string // Final audio file path (already renamed from .tmp)
+	Track        Track
+	Extractor    string // yt-dlp extractor name, e.g. "youtube"
+	VideoID      string // yt-dlp video id
+	InfoJSONPath string // Sidecar written by --write-info-json, if present
+}
+
+// Processor is one stage of post-download processing: tagging, cover art,
+// loudness normalization, uploading, or anything else that needs to touch
+// the finished file before the track is declared done.
+type Processor interface {
+	Process(ctx context.Context, tf *TrackFile) error
+}
+
+// ProcessorStage pairs a Processor with whether its failure should abort
+// the chain or just be logged and skipped.
+type ProcessorStage struct {
+	Name      string // Used in log output and wrapped errors
+	Processor Processor
+	WarnOnly  bool // Demote this stage's failures to a warning instead of aborting the chain
+}
+
+// ProcessorChain runs a fixed sequence of ProcessorStages over a TrackFile,
+// wired onto Downloader via SetProcessorChain and invoked once per
+// successful download from downloadTrack.
+type ProcessorChain struct {
+	stages []ProcessorStage
+}
+
+// NewProcessorChain builds a chain that runs stages in order.
+func NewProcessorChain(stages ...ProcessorStage) *ProcessorChain {
+	return &ProcessorChain{stages: stages}
+}
+
+// Run executes every stage in order against tf. A WarnOnly stage that fails
+// is logged and skipped; any other failure aborts the chain and is
+// returned wrapped with the stage's name.
+func (c *ProcessorChain) Run(ctx context.Context, tf *TrackFile) error {
+	for _, stage := range c.stages {
+		if err := stage.Processor.Process(ctx, tf); err != nil {
+			if stage.WarnOnly {
+				log.Printf("⚠️  %s failed, continuing: %v", stage.Name, err)
+				continue
+			}
+			return fmt.Errorf("%s: %w", stage.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"golang.org/x/term"
+)
+
+// Stage identifies which phase of a download an event describes.
+type Stage int
+
+const (
+	StageSearching Stage = iota
+	StageDownloading
+	StageDone
+	StageFailed
+	StageSkipped
+	StageStateSkipped
+)
+
+// ProgressEvent carries one update out of downloadTrack's stdout/stderr
+// readers, or a track's final outcome out of worker(), instead of either
+// printing directly, so a ProgressRenderer can decide how to present it.
+type ProgressEvent struct {
+	Worker     int
+	Track      Track
+	Stage      Stage
+	BytesDone  int64
+	BytesTotal int64
+	ETA        string
+	Class      FailureClass // Set on StageFailed
+	Count      int          // Set on StageDone/StageFailed/StageSkipped/StageStateSkipped: tracks completed so far, including this one
+	Total      int          // Set on StageDone/StageFailed/StageSkipped/StageStateSkipped: total tracks for this run
+}
+
+// ProgressRenderer consumes the ProgressEvent stream produced by the worker
+// pool. TTYRenderer draws one sticky bar per worker plus a totals bar;
+// PlainRenderer falls back to the original line-per-event output.
+type ProgressRenderer interface {
+	// Run consumes events until the channel is closed.
+	Run(events <-chan ProgressEvent)
+}
+
+// newProgressRenderer picks TTYRenderer when stdout is a terminal and
+// --no-tty wasn't passed, and PlainRenderer otherwise.
+func newProgressRenderer(workers, total int, noTTY bool) ProgressRenderer {
+	if !noTTY && term.IsTerminal(int(os.Stdout.Fd())) {
+		return NewTTYRenderer(workers, total)
+	}
+	return &PlainRenderer{}
+}
+
+// progressTemplate is passed to yt-dlp via --progress-template so its
+// stdout carries a single, easily parsed line per progress tick instead of
+// the human-oriented progress bar yt-dlp prints by default.
+const progressTemplate = "MD-PROGRESS %(progress.downloaded_bytes)s %(progress.total_bytes)s %(progress.eta)s"
+
+var progressLineRe = regexp.MustCompile(`^MD-PROGRESS\s+(\S+)\s+(\S+)\s+(\S+)`)
+
+// parseProgressLine extracts downloaded/total bytes and ETA from a line
+// produced by progressTemplate. ok is false for lines that aren't progress
+// output (search/extraction chatter, warnings, etc).
+func parseProgressLine(line string) (downloaded, total int64, eta string, ok bool) {
+	m := progressLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return 0, 0, "", false
+	}
+	downloaded, _ = strconv.ParseInt(m[1], 10, 64)
+	total, _ = strconv.ParseInt(m[2], 10, 64)
+	eta = m[3]
+	return downloaded, total, eta, true
+}
+
+// metaTemplate is passed to yt-dlp via --print so its stdout also carries
+// the matched video's extractor and id, for the state store.
+const metaTemplate = "MD-META %(extractor)s %(id)s"
+
+var metaLineRe = regexp.MustCompile(`^MD-META\s+(\S+)\s+(\S+)`)
+
+// parseMetaLine extracts the extractor and video id from a line produced by
+// metaTemplate. ok is false for any other line.
+func parseMetaLine(line string) (extractor, videoID string, ok bool) {
+	m := metaLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// TTYRenderer draws one sticky progress bar per worker slot plus a totals
+// bar counting completed/total tracks, using a cheggaaa/pb pool so bars
+// don't interleave when running with multiple workers.
+type TTYRenderer struct {
+	pool   *pb.Pool
+	bars   []*pb.ProgressBar
+	total  *pb.ProgressBar
+	done   int
+	target int
+	mu     sync.Mutex
+}
+
+// NewTTYRenderer builds bars for `workers` worker slots plus one totals bar
+// tracking progress toward `total` tracks.
+func NewTTYRenderer(workers, total int) *TTYRenderer {
+	r := &TTYRenderer{target: total}
+	r.bars = make([]*pb.ProgressBar, workers)
+	for i := range r.bars {
+		r.bars[i] = pb.New64(0).SetTemplateString(`{{ string . "track" }} {{ bar . }} {{ percent . }} {{ string . "eta" }}`)
+		r.bars[i].Set("track", fmt.Sprintf("worker %d: idle", i+1))
+	}
+	r.total = pb.New(total).SetTemplateString(`Total {{ bar . }} {{ counters . }}`)
+
+	bars := append(append([]*pb.ProgressBar{}, r.bars...), r.total)
+	r.pool, _ = pb.StartPool(bars...)
+	return r
+}
+
+func (r *TTYRenderer) Run(events <-chan ProgressEvent) {
+	for ev := range events {
+		r.mu.Lock()
+		// A state-store skip (StageStateSkipped) happens at dispatch time,
+		// before any worker is assigned, so it carries no Worker slot to
+		// update; it still counts toward the totals bar below.
+		if ev.Worker-1 >= 0 && ev.Worker-1 < len(r.bars) {
+			bar := r.bars[ev.Worker-1]
+			label := fmt.Sprintf("%s - %s", ev.Track.Artist, ev.Track.Title)
+			switch ev.Stage {
+			case StageSearching:
+				bar.Set("track", fmt.Sprintf("worker %d: searching %s", ev.Worker, label))
+				bar.SetTotal(1)
+				bar.SetCurrent(0)
+			case StageDownloading:
+				bar.Set("track", fmt.Sprintf("worker %d: %s", ev.Worker, label))
+				bar.Set("eta", ev.ETA)
+				if ev.BytesTotal > 0 {
+					bar.SetTotal(ev.BytesTotal)
+				}
+				bar.SetCurrent(ev.BytesDone)
+			case StageDone, StageFailed, StageSkipped:
+				bar.Set("track", fmt.Sprintf("worker %d: idle", ev.Worker))
+				bar.SetCurrent(bar.Total())
+			}
+		}
+		switch ev.Stage {
+		case StageDone, StageFailed, StageSkipped, StageStateSkipped:
+			// In --watch mode ev.Total grows as playlists keep arriving, so
+			// the bar's max has to keep up with it instead of staying at
+			// whatever it was constructed with.
+			if ev.Total > r.target {
+				r.target = ev.Total
+				r.total.SetTotal(int64(r.target))
+			}
+			r.done++
+			r.total.SetCurrent(int64(r.done))
+		}
+		r.mu.Unlock()
+	}
+	r.pool.Stop()
+}
+
+// PlainRenderer reproduces the original line-per-event output, used when
+// stdout isn't a TTY (piped/redirected output, CI logs) or --no-tty is set.
+type PlainRenderer struct{}
+
+func (r *PlainRenderer) Run(events <-chan ProgressEvent) {
+	shown := make(map[string]bool)
+	for ev := range events {
+		key := fmt.Sprintf("%d:%s", ev.Worker, ev.Track.Raw)
+		switch ev.Stage {
+		case StageSearching:
+			fmt.Printf("🔍 [worker %d] Searching: %s - %s\n", ev.Worker, ev.Track.Artist, ev.Track.Title)
+		case StageDownloading:
+			if !shown[key+":dl"] {
+				fmt.Printf("⬇️  [worker %d] Downloading: %s - %s\n", ev.Worker, ev.Track.Artist, ev.Track.Title)
+				shown[key+":dl"] = true
+			}
+		case StageSkipped:
+			fmt.Printf("⏭️  [%d/%d] Already exists: %s\n", ev.Count, ev.Total, ev.Track.Raw)
+		case StageStateSkipped:
+			fmt.Printf("⏭️  [%d/%d] Skipped (state store): %s\n", ev.Count, ev.Total, ev.Track.Raw)
+		case StageDone:
+			fmt.Printf("✅ [%d/%d] Downloaded: %s\n", ev.Count, ev.Total, ev.Track.Raw)
+		case StageFailed:
+			fmt.Printf("❌ [%d/%d] Failed: %s [%s]\n", ev.Count, ev.Total, ev.Track.Raw, ev.Class)
+		}
+	}
+}
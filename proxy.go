@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// proxyEntry tracks health for a single proxy URL (or "" for a direct
+// connection, which the pool always keeps available as a last resort).
+type proxyEntry struct {
+	url             string
+	successes       int
+	failures        int
+	consecutive429s int
+	cooldownUntil   time.Time
+}
+
+func (e *proxyEntry) available(now time.Time) bool {
+	return now.After(e.cooldownUntil) || now.Equal(e.cooldownUntil)
+}
+
+// ProxyPool replaces the single PROXY_URL constant with a set of proxies
+// (plus an implicit "direct" entry) that downloadTrack rotates through
+// round-robin, parking unhealthy ones for an exponentially growing
+// cooldown instead of hammering them.
+type ProxyPool struct {
+	mu      sync.Mutex
+	entries []*proxyEntry
+	next    int
+}
+
+// NewProxyPool builds a pool from a comma-separated list of proxy URLs
+// (http://, socks5://, or the literal "direct" for no proxy).
+func NewProxyPool(urls []string) *ProxyPool {
+	pool := &ProxyPool{}
+	for _, u := range urls {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		if strings.EqualFold(u, "direct") {
+			u = ""
+		}
+		pool.entries = append(pool.entries, &proxyEntry{url: u})
+	}
+	if len(pool.entries) == 0 {
+		pool.entries = append(pool.entries, &proxyEntry{url: ""})
+	}
+	return pool
+}
+
+// loadProxyPoolFromEnv reads the PROXIES env var (comma-separated, as
+// described on ProxyPool) and falls back to the legacy single PROXY_URL
+// constant when it's unset, so existing configs keep working.
+func loadProxyPoolFromEnv() *ProxyPool {
+	if raw := os.Getenv("PROXIES"); raw != "" {
+		return NewProxyPool(strings.Split(raw, ","))
+	}
+	return NewProxyPool([]string{PROXY_URL})
+}
+
+// Acquire returns the next healthy proxy in round-robin order, skipping
+// entries still in cooldown. If every entry is cooling down it returns the
+// one closest to recovering rather than blocking.
+func (p *ProxyPool) Acquire() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	best := -1
+	for i := 0; i < len(p.entries); i++ {
+		idx := (p.next + i) % len(p.entries)
+		if p.entries[idx].available(now) {
+			p.next = idx + 1
+			return p.entries[idx].url
+		}
+		if best == -1 || p.entries[idx].cooldownUntil.Before(p.entries[best].cooldownUntil) {
+			best = idx
+		}
+	}
+	p.next = best + 1
+	return p.entries[best].url
+}
+
+// AcquirePreferring returns preferred if it names a known, currently healthy
+// entry, letting a retry stick with the same proxy when its RetryPolicy
+// didn't call for rotation. Otherwise it falls back to the normal
+// round-robin Acquire.
+func (p *ProxyPool) AcquirePreferring(preferred string) string {
+	p.mu.Lock()
+	entry := p.find(preferred)
+	if entry != nil && entry.available(time.Now()) {
+		p.mu.Unlock()
+		return preferred
+	}
+	p.mu.Unlock()
+	return p.Acquire()
+}
+
+// Report records the outcome of an attempt made through proxyURL so future
+// Acquire calls can route around unhealthy proxies.
+func (p *ProxyPool) Report(proxyURL string, result DownloadResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry := p.find(proxyURL)
+	if entry == nil {
+		return
+	}
+
+	if result.Success {
+		entry.successes++
+		entry.consecutive429s = 0
+		return
+	}
+
+	entry.failures++
+	if result.Class == ErrRateLimited {
+		entry.consecutive429s++
+		entry.cooldownUntil = time.Now().Add(cooldownFor(entry.consecutive429s))
+	} else if result.Class == ErrProxy || result.Class == ErrDNS {
+		entry.cooldownUntil = time.Now().Add(30 * time.Second)
+	}
+}
+
+func cooldownFor(consecutive429s int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < consecutive429s; i++ {
+		d *= 2
+		if d > 30*time.Minute {
+			return 30 * time.Minute
+		}
+	}
+	return d
+}
+
+func (p *ProxyPool) find(url string) *proxyEntry {
+	for _, e := range p.entries {
+		if e.url == url {
+			return e
+		}
+	}
+	return nil
+}
+
+// Probe HEADs a known-good URL through every proxy in the pool and parks
+// (with an indefinite cooldown) any that fail to respond, so a startup
+// probe prunes dead entries before the first track is dispatched.
+func (p *ProxyPool) Probe(ctx context.Context, probeURL string) {
+	p.mu.Lock()
+	entries := append([]*proxyEntry{}, p.entries...)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(e *proxyEntry) {
+			defer wg.Done()
+			if !probeProxy(ctx, e.url, probeURL) {
+				p.mu.Lock()
+				e.cooldownUntil = time.Now().Add(30 * time.Minute)
+				p.mu.Unlock()
+				label := e.url
+				if label == "" {
+					label = "direct"
+				}
+				fmt.Printf("⚠️  Proxy probe failed, parking %s\n", label)
+			}
+		}(entry)
+	}
+	wg.Wait()
+}
+
+func probeProxy(ctx context.Context, proxyURL, probeURL string) bool {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if proxyURL != "" {
+		transport, err := transportForProxy(proxyURL)
+		if err != nil {
+			return false
+		}
+		client.Transport = transport
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, probeURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode < 500
+}
+
+// transportForProxy builds an http.Transport that routes through proxyURL,
+// understanding both http(s):// and socks5:// schemes.
+func transportForProxy(proxyURL string) (*http.Transport, error) {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proxy URL %q: %w", proxyURL, err)
+	}
+
+	if strings.HasPrefix(parsed.Scheme, "socks5") {
+		dialer, err := proxy.FromURL(parsed, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("building socks5 dialer for %q: %w", proxyURL, err)
+		}
+		return &http.Transport{Dial: dialer.Dial}, nil
+	}
+
+	return &http.Transport{Proxy: http.ProxyURL(parsed)}, nil
+}
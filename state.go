@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrackState records everything the state store knows about one track's
+// last attempt, keyed by trackKey. It's the source of truth for deciding
+// whether a rerun can skip a track instead of re-running yt-dlp on it.
+type TrackState struct {
+	Filename    string        // Final (non-.tmp) path written on success
+	Codec       string        // Audio codec, e.g. "mp3"
+	Bitrate     string        // Audio bitrate, e.g. "192K"
+	Duration    time.Duration // Track length, copied from Track at record time
+	Extractor   string        // yt-dlp extractor name for the matched video, e.g. "youtube"
+	VideoID     string        // yt-dlp video id for the matched video
+	LastAttempt time.Time     // When this record was last written
+	Attempts    int           // Attempts made across all runs
+	LastClass   FailureClass  // Zero value (ErrUnknown) is meaningless until Attempts > 0
+	Success     bool          // Whether the last attempt succeeded
+}
+
+// nonRetryableClasses mirrors the RetryPolicy entries with MaxAttempts: 0 —
+// failures the downloader has already learned the answer to, so a plain
+// rerun shouldn't burn another attempt on them.
+var nonRetryableClasses = map[FailureClass]bool{
+	ErrGeoBlocked:       true,
+	ErrAgeRestricted:    true,
+	ErrCopyrightBlocked: true,
+	ErrPermanentHTTP:    true,
+}
+
+// StateStore persists TrackState across runs in a single JSON file, so
+// "skip existing" can move beyond a filesystem stat over a fixed extension
+// list and reruns can skip tracks already known to be permanently
+// unreachable instead of re-querying yt-dlp for the same answer.
+type StateStore struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]TrackState
+}
+
+// LoadStateStore reads path if it exists, or returns an empty store ready
+// to be populated and saved there. A missing or corrupt file is treated as
+// an empty store rather than an error, since the store is a cache, not a
+// record of truth the user maintains by hand.
+func LoadStateStore(path string) *StateStore {
+	store := &StateStore{path: path, entries: make(map[string]TrackState)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return store
+	}
+	// A corrupt file is treated the same as a missing one: start fresh.
+	if err := json.Unmarshal(data, &store.entries); err != nil {
+		store.entries = make(map[string]TrackState)
+	}
+
+	return store
+}
+
+// trackKey derives a stable key for track from its artist and title, so
+// renumbering the playlist or tweaking unrelated fields doesn't invalidate
+// the recorded state.
+func trackKey(track Track) string {
+	sum := sha1.Sum([]byte(track.Artist + "|" + track.Title))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the recorded state for track, if any.
+func (s *StateStore) Get(track Track) (TrackState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.entries[trackKey(track)]
+	return state, ok
+}
+
+// Record stores state for track, overwriting whatever was there before.
+func (s *StateStore) Record(track Track, state TrackState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[trackKey(track)] = state
+}
+
+// ShouldSkip reports whether track can be skipped outright: either its
+// recorded file still exists and passes an integrity check, or its last
+// failure is permanent and not worth re-querying yt-dlp for.
+func (s *StateStore) ShouldSkip(track Track) bool {
+	state, ok := s.Get(track)
+	if !ok {
+		return false
+	}
+	if state.Success && fileLooksIntact(state.Filename) {
+		return true
+	}
+	return nonRetryableClasses[state.LastClass]
+}
+
+// fileLooksIntact is a cheap integrity check: the recorded file exists and
+// is non-empty. It doesn't re-decode the audio, just guards against a
+// truncated or since-deleted file being treated as done.
+func fileLooksIntact(filename string) bool {
+	if filename == "" {
+		return false
+	}
+	info, err := os.Stat(filename)
+	return err == nil && info.Size() > 0
+}
+
+// Save writes the store to its path as JSON, via a temp file + rename so a
+// crash mid-write can't leave a half-written state file behind.
+func (s *StateStore) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshaling state store: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing state store: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// failureClassByName maps a normalized (lowercased, "-"/"_"/" " stripped)
+// name to its FailureClass, for parsing --retry-failed.
+var failureClassByName = map[string]FailureClass{
+	"unknown":          ErrUnknown,
+	"ratelimited":      ErrRateLimited,
+	"geoblocked":       ErrGeoBlocked,
+	"agerestricted":    ErrAgeRestricted,
+	"copyrightblocked": ErrCopyrightBlocked,
+	"dns":              ErrDNS,
+	"proxy":            ErrProxy,
+	"transienthttp":    ErrTransientHTTP,
+	"permanenthttp":    ErrPermanentHTTP,
+	"notfound":         ErrNotFound,
+}
+
+// parseFailureClasses parses a comma-separated list of FailureClass names
+// (case-insensitive, "-"/"_"/" " optional, e.g. "rate-limited,proxy,dns") as
+// used by --retry-failed. Unknown names are ignored.
+func parseFailureClasses(csv string) map[FailureClass]bool {
+	classes := make(map[FailureClass]bool)
+	for _, name := range strings.Split(csv, ",") {
+		name = normalizeClassName(name)
+		if name == "" {
+			continue
+		}
+		if class, ok := failureClassByName[name]; ok {
+			classes[class] = true
+		}
+	}
+	return classes
+}
+
+func normalizeClassName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer("-", "", "_", "", " ", "").Replace(name)
+	return name
+}
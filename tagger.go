@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// trackInfoJSON is the subset of yt-dlp's --write-info-json sidecar that
+// ID3Tagger and CoverArtEmbedder care about. yt-dlp's real schema has
+// dozens more fields; everything else is ignored.
+type trackInfoJSON struct {
+	Album     string `json:"album"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+// readTrackInfoJSON loads and parses tf.InfoJSONPath, returning a zero
+// value if the sidecar is missing (e.g. yt-dlp was run without
+// --write-info-json, or writing it failed) rather than an error, since the
+// sidecar is supplementary metadata, not something tagging can't proceed
+// without.
+func readTrackInfoJSON(path string) trackInfoJSON {
+	var info trackInfoJSON
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info
+	}
+	json.Unmarshal(data, &info)
+	return info
+}
+
+// ID3Tagger writes TPE1 (artist), TIT2 (title) and TALB (album) from the
+// Track struct, falling back to the yt-dlp info json's album field when
+// the playlist source didn't supply one.
+type ID3Tagger struct{}
+
+func (t *ID3Tagger) Process(ctx context.Context, tf *TrackFile) error {
+	tag, err := id3v2.Open(tf.Path, id3v2.Options{Parse: true})
+	if err != nil {
+		return fmt.Errorf("opening %s for tagging: %w", tf.Path, err)
+	}
+	defer tag.Close()
+
+	tag.SetDefaultEncoding(id3v2.EncodingUTF8)
+	tag.SetArtist(tf.Track.Artist)
+	tag.SetTitle(tf.Track.Title)
+
+	album := tf.Track.Album
+	if album == "" {
+		album = readTrackInfoJSON(tf.InfoJSONPath).Album
+	}
+	if album != "" {
+		tag.SetAlbum(album)
+	}
+
+	if err := tag.Save(); err != nil {
+		return fmt.Errorf("saving ID3 tags for %s: %w", tf.Path, err)
+	}
+	return nil
+}
+
+// CoverArtEmbedder embeds cover art as an APIC frame, preferring the
+// thumbnail yt-dlp already recorded in the info json, then the YouTube
+// thumbnail CDN directly, then a MusicBrainz/Cover Art Archive lookup by
+// artist+title.
+type CoverArtEmbedder struct {
+	HTTPClient *http.Client
+}
+
+// NewCoverArtEmbedder builds an embedder with a bounded-timeout client, so
+// a slow or hanging image host doesn't stall the worker indefinitely.
+func NewCoverArtEmbedder() *CoverArtEmbedder {
+	return &CoverArtEmbedder{HTTPClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (c *CoverArtEmbedder) Process(ctx context.Context, tf *TrackFile) error {
+	image, mimeType, err := c.fetchCoverArt(ctx, tf)
+	if err != nil {
+		return err
+	}
+
+	tag, err := id3v2.Open(tf.Path, id3v2.Options{Parse: true})
+	if err != nil {
+		return fmt.Errorf("opening %s for cover art: %w", tf.Path, err)
+	}
+	defer tag.Close()
+
+	tag.AddAttachedPicture(id3v2.PictureFrame{
+		Encoding:    id3v2.EncodingUTF8,
+		MimeType:    mimeType,
+		PictureType: id3v2.PTFrontCover,
+		Description: "Cover",
+		Picture:     image,
+	})
+
+	if err := tag.Save(); err != nil {
+		return fmt.Errorf("saving cover art for %s: %w", tf.Path, err)
+	}
+	return nil
+}
+
+func (c *CoverArtEmbedder) fetchCoverArt(ctx context.Context, tf *TrackFile) ([]byte, string, error) {
+	if thumb := readTrackInfoJSON(tf.InfoJSONPath).Thumbnail; thumb != "" {
+		if image, mimeType, err := c.download(ctx, thumb); err == nil {
+			return image, mimeType, nil
+		}
+	}
+
+	if tf.Extractor == "youtube" && tf.VideoID != "" {
+		youtubeThumb := fmt.Sprintf("https://i.ytimg.com/vi/%s/maxresdefault.jpg", tf.VideoID)
+		if image, mimeType, err := c.download(ctx, youtubeThumb); err == nil {
+			return image, mimeType, nil
+		}
+	}
+
+	if coverURL, err := c.lookupCoverArtArchive(ctx, tf.Track.Artist, tf.Track.Title); err == nil {
+		return c.download(ctx, coverURL)
+	}
+
+	return nil, "", fmt.Errorf("no cover art found for %s - %s", tf.Track.Artist, tf.Track.Title)
+}
+
+// lookupCoverArtArchive resolves artist+title to a MusicBrainz release,
+// then returns that release's Cover Art Archive front image URL.
+func (c *CoverArtEmbedder) lookupCoverArtArchive(ctx context.Context, artist, title string) (string, error) {
+	query := fmt.Sprintf(`artist:"%s" AND recording:"%s"`, artist, title)
+	searchURL := "https://musicbrainz.org/ws/2/recording/?query=" + url.QueryEscape(query) + "&fmt=json&limit=1"
+
+	var search struct {
+		Recordings []struct {
+			Releases []struct {
+				ID string `json:"id"`
+			} `json:"releases"`
+		} `json:"recordings"`
+	}
+	if err := c.getJSON(ctx, searchURL, &search); err != nil {
+		return "", fmt.Errorf("musicbrainz lookup for %s - %s: %w", artist, title, err)
+	}
+	if len(search.Recordings) == 0 || len(search.Recordings[0].Releases) == 0 {
+		return "", fmt.Errorf("no musicbrainz release found for %s - %s", artist, title)
+	}
+
+	releaseID := search.Recordings[0].Releases[0].ID
+	return fmt.Sprintf("https://coverartarchive.org/release/%s/front", releaseID), nil
+}
+
+func (c *CoverArtEmbedder) getJSON(ctx context.Context, requestURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "music-downloader/1.0 ( https://github.com/manson/music-downloader )")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *CoverArtEmbedder) download(ctx context.Context, imageURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %s: status %d", imageURL, resp.StatusCode)
+	}
+
+	mimeType := resp.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+
+	image, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return image, mimeType, nil
+}
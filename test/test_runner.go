@@ -27,8 +27,10 @@ func main() {
 	fmt.Println("Note: Test runs with default proxy settings (http://localhost:8881)")
 	fmt.Println("To disable proxy, change PROXY_URL to empty string in main.go")
 
-	// Run the downloader from parent directory
-	cmd := exec.Command("go", "run", "../main.go")
+	// Run the downloader from parent directory. "go run ." (not a single
+	// file) is required since the package is split across multiple .go
+	// files in that directory.
+	cmd := exec.Command("go", "run", ".")
 	cmd.Dir = ".."
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// UploadSink pushes a finished local file to remote storage.
+type UploadSink interface {
+	Upload(ctx context.Context, localPath string) error
+}
+
+// S3Sink uploads to an S3 (or S3-compatible) bucket under Prefix, using
+// credentials resolved the normal AWS SDK way (env vars, shared config,
+// instance role, ...).
+type S3Sink struct {
+	Bucket string
+	Prefix string
+	Client *s3.Client
+}
+
+// NewS3Sink loads the default AWS config and builds an S3Sink targeting
+// bucket, keying uploads under prefix.
+func NewS3Sink(ctx context.Context, bucket, prefix string) (*S3Sink, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return &S3Sink{Bucket: bucket, Prefix: prefix, Client: s3.NewFromConfig(cfg)}, nil
+}
+
+func (s *S3Sink) Upload(ctx context.Context, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	key := path.Join(s.Prefix, filepath.Base(localPath))
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+	if err != nil {
+		return fmt.Errorf("uploading %s to s3://%s/%s: %w", localPath, s.Bucket, key, err)
+	}
+	return nil
+}
+
+// WebDAVSink uploads via a plain HTTP PUT to BaseURL + the file's
+// basename, the way any WebDAV server (Nextcloud, nginx's dav module,
+// rclone serve webdav, ...) expects.
+type WebDAVSink struct {
+	BaseURL    string
+	Username   string // Empty disables basic auth
+	Password   string
+	HTTPClient *http.Client
+}
+
+// NewWebDAVSink builds a sink PUTting files under baseURL, optionally with
+// basic auth when username is non-empty.
+func NewWebDAVSink(baseURL, username, password string) *WebDAVSink {
+	return &WebDAVSink{
+		BaseURL:    baseURL,
+		Username:   username,
+		Password:   password,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (w *WebDAVSink) Upload(ctx context.Context, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", localPath, err)
+	}
+
+	target := strings.TrimRight(w.BaseURL, "/") + "/" + filepath.Base(localPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, file)
+	if err != nil {
+		return fmt.Errorf("building PUT request for %s: %w", target, err)
+	}
+	req.ContentLength = info.Size()
+	if w.Username != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("PUT %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PUT %s: unexpected status %d", target, resp.StatusCode)
+	}
+	return nil
+}
+
+// UploadProcessor wires an UploadSink into the post-processing chain,
+// optionally deleting the local copy once the remote upload succeeds.
+type UploadProcessor struct {
+	Sink        UploadSink
+	DeleteLocal bool
+}
+
+func (p *UploadProcessor) Process(ctx context.Context, tf *TrackFile) error {
+	if err := p.Sink.Upload(ctx, tf.Path); err != nil {
+		return fmt.Errorf("uploading %s: %w", tf.Path, err)
+	}
+	if p.DeleteLocal {
+		if err := os.Remove(tf.Path); err != nil {
+			return fmt.Errorf("removing local copy of %s after upload: %w", tf.Path, err)
+		}
+	}
+	return nil
+}